@@ -18,10 +18,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+
 	stringsets "k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 
@@ -35,6 +38,109 @@ type Requirements struct {
 	// Requirements are layered with Labels and applied to every node.
 	Requirements []v1.NodeSelectorRequirement `json:"requirements,omitempty"`
 	requirements map[string]sets.Set          `json:"-"`
+	// ranges holds the Gt/Lt numeric bounds for a key, layered on top of (and narrowing) any
+	// discrete Set tracked in requirements for that same key. A key may carry both, e.g.
+	// "memory Gt 4096" alongside "memory In [2048, 4096, 8192, 16384]".
+	ranges map[string][]sets.Range `json:"-"`
+	// nodeAffinity evaluates the pod's node selector and required node affinity the same way
+	// kube-scheduler's nodeaffinity plugin does, so that OR semantics between NodeSelectorTerms
+	// and lazy per-term parse errors are preserved. It is nil for Requirements that weren't built
+	// from a pod (e.g. instance type or offering requirements), in which case Match always passes.
+	nodeAffinity *nodeaffinity.RequiredNodeAffinity `json:"-"`
+	// requiredTerms holds, for a pod with more than one required NodeSelectorTerm, each term's own
+	// discrete Set/Range fold (nodeSelector AND'd with that term's MatchExpressions). It lets
+	// Compatible try every term independently and succeed if any one is satisfiable, mirroring the
+	// OR semantics Match gets from nodeAffinity. It's nil whenever a single term (or none) covers
+	// the object, in which case Compatible falls back to requirements/ranges directly, as before.
+	requiredTerms []requirementTerm `json:"-"`
+	// Preferences holds the pod's PreferredDuringSchedulingIgnoredDuringExecution terms, kept out
+	// of Requirements entirely so they never affect feasibility. Score uses them to break ties
+	// between otherwise-feasible candidates.
+	Preferences []PreferredTerm `json:"-"`
+}
+
+// PreferredTerm is a single weighted NodeAffinity preference, reduced to the same set-based
+// representation Requirements uses internally so it can be scored against a candidate cheaply.
+type PreferredTerm struct {
+	Weight int32
+
+	requirements map[string]sets.Set
+	ranges       map[string][]sets.Range
+}
+
+func newPreferredTerm(term v1.PreferredSchedulingTerm) PreferredTerm {
+	parsed := NewRequirements(term.Preference.MatchExpressions...)
+	return PreferredTerm{
+		Weight:       term.Weight,
+		requirements: parsed.requirements,
+		ranges:       parsed.ranges,
+	}
+}
+
+// Satisfied reports whether candidate admits at least one value for every key this term
+// constrains, i.e. whether the term's MatchExpressions (which are AND'd, like a NodeSelectorTerm)
+// all hold. A Range only narrows a concrete, enumerable Set (Range.Intersect is a no-op on a
+// complement Set), so a Gt/Lt bound declared on this term with no sibling discrete value for that
+// key would never be enforced if it were only applied to p's own raw set - candidate.Get(key)
+// would stay an unnarrowed complement Set on both sides and the intersection would never come up
+// empty. Apply every Range registered for key, from both p and candidate, to both sides' raw Sets
+// before intersecting, the same way Compatible does.
+func (p PreferredTerm) Satisfied(candidate Requirements) bool {
+	keys := stringsets.NewString()
+	for key := range p.requirements {
+		keys.Insert(key)
+	}
+	for key := range p.ranges {
+		keys.Insert(key)
+	}
+	for _, key := range keys.UnsortedList() {
+		var ranges []sets.Range
+		ranges = append(ranges, p.ranges[key]...)
+		ranges = append(ranges, candidate.ranges[key]...)
+		narrow := func(values sets.Set) sets.Set {
+			for _, rng := range ranges {
+				values = rng.Intersect(values)
+			}
+			return values
+		}
+		if narrow(p.rawSet(key)).Intersection(narrow(candidate.rawSet(key))).Len() == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rawSet returns the term's discrete Set for key without narrowing it by the term's own ranges,
+// mirroring Requirements.rawSet.
+func (p PreferredTerm) rawSet(key string) sets.Set {
+	if values, ok := p.requirements[key]; ok {
+		return values
+	}
+	if _, hasRange := p.ranges[key]; hasRange {
+		return sets.NewComplementSet()
+	}
+	return p.requirements[key]
+}
+
+// requirementTerm is one required NodeSelectorTerm's discrete Set/Range fold, the same shape
+// Requirements.requirements/ranges uses for the whole object. Compatible evaluates each of an
+// object's requirementTerms independently so that an OR across required terms isn't collapsed
+// into picking just one of them.
+type requirementTerm struct {
+	requirements map[string]sets.Set
+	ranges       map[string][]sets.Range
+}
+
+// rawSet returns the term's discrete Set for key without narrowing it by the term's own ranges,
+// mirroring Requirements.rawSet.
+func (t requirementTerm) rawSet(key string) sets.Set {
+	if values, ok := t.requirements[key]; ok {
+		return values
+	}
+	if _, hasRange := t.ranges[key]; hasRange {
+		return sets.NewComplementSet()
+	}
+	return t.requirements[key]
 }
 
 // NewRequirements constructs requirements from NodeSelectorRequirements
@@ -57,27 +163,94 @@ func NewPodRequirements(pod *v1.Pod) Requirements {
 	for key, value := range pod.Spec.NodeSelector {
 		requirements = append(requirements, v1.NodeSelectorRequirement{Key: key, Operator: v1.NodeSelectorOpIn, Values: []string{value}})
 	}
+	r := NewRequirements(requirements...)
+	// GetRequiredNodeAffinity wraps pod.Spec.NodeSelector and (if present) required node affinity in
+	// a LazyErrorNodeSelector; it tolerates a nil Affinity/NodeAffinity on its own, so this must run
+	// even for a pod with no affinity block at all - otherwise Match would stay a no-op and silently
+	// skip checking nodeSelector against a candidate node.
+	nodeAffinity := nodeaffinity.GetRequiredNodeAffinity(pod)
+	r.nodeAffinity = &nodeAffinity
 	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil {
-		return NewRequirements(requirements...)
+		return r
 	}
 	// The legal operators for pod affinity and anti-affinity are In, NotIn, Exists, DoesNotExist.
-	// Select heaviest preference and treat as a requirement. An outer loop will iteratively unconstrain them if unsatisfiable.
-	if preferred := pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution; len(preferred) > 0 {
-		sort.Slice(preferred, func(i int, j int) bool { return preferred[i].Weight > preferred[j].Weight })
-		requirements = append(requirements, preferred[0].Preference.MatchExpressions...)
+	// Every preferred term is kept, not just the heaviest one, so Score (below) can sum the weights
+	// of whichever terms a candidate satisfies instead of guessing which single term matters most.
+	// Score is only the scoring primitive: the scheduling loop that would call it to rank otherwise-
+	// feasible instance types/zones/capacity types, and the fallback that relaxes one preference at
+	// a time when it makes a pod unschedulable, aren't implemented in this package.
+	for _, term := range pod.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		r.Preferences = append(r.Preferences, newPreferredTerm(term))
 	}
-	// Select first requirement. An outer loop will iteratively remove OR requirements if unsatisfiable
-	if pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil &&
-		len(pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) > 0 {
-		requirements = append(requirements, pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions...)
+	// Required node affinity feeds two separate consumers now. nodeaffinity.GetRequiredNodeAffinity
+	// wraps nodeSelector and RequiredDuringSchedulingIgnoredDuringExecution in a
+	// LazyErrorNodeSelector, preserving OR semantics between terms and only surfacing a term's
+	// parse error if that term would otherwise have matched; Requirements.Match evaluates it once a
+	// concrete *v1.Node exists. Compatible can't call Match - no *v1.Node exists yet during
+	// instance-type/zone/capacity-type selection - but it doesn't have to collapse the OR into a
+	// single term either: requiredTerms below folds nodeSelector AND'd with each term's own
+	// MatchExpressions independently, so Compatible can try every term and accept a candidate that
+	// satisfies any one of them, the same way Match would once a node exists.
+	if pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		if len(terms) > 0 {
+			// Get/Zones/InstanceTypes/CapacityTypes narrow a single discrete Set per key, which
+			// can't represent an OR of terms; keep folding just the first term into r's own
+			// requirements/ranges for those callers, same as before.
+			r = r.Add(terms[0].MatchExpressions...)
+		}
+		if len(terms) > 1 {
+			for _, term := range terms {
+				folded := NewRequirements(requirements...).Add(term.MatchExpressions...)
+				r.requiredTerms = append(r.requiredTerms, requirementTerm{requirements: folded.requirements, ranges: folded.ranges})
+			}
+		}
 	}
-	return NewRequirements(requirements...)
+	return r
+}
+
+// Union folds added's requirements into r, the same way the upstream node-affinity plugin's
+// AddedAffinity is unioned into every pod it scores. It's the primitive a cluster-wide "added
+// requirements" feature would call on podReqs before Compatible, so a requirement supplied outside
+// the pod spec (e.g. a future Provisioner-level or controller-flag AddedRequirements) constrains
+// every provisioning decision the same way a requirement on the pod itself would - but Union only
+// merges two already-built Requirements objects. The Provisioner API field, admission-time
+// validation, and the scheduler call site that would thread such a value through the scheduling
+// context don't exist yet; this method alone doesn't implement that feature, only the step it
+// would need. Union does still reject added itself so at least this step can't silently accept a
+// malformed requirement.
+func (r Requirements) Union(added Requirements) (Requirements, error) {
+	if err := added.Validate(); err != nil {
+		return r, fmt.Errorf("invalid added requirements, %w", err)
+	}
+	return r.Add(added.Requirements...), nil
 }
 
 // Add function returns a new Requirements object with new requirements inserted.
 func (r Requirements) Add(requirements ...v1.NodeSelectorRequirement) Requirements {
 	// Deep copy to avoid mutating existing requirements
 	cp := *r.DeepCopy()
+	// zz_generated.deepcopy doesn't know about nodeAffinity; it's immutable once built so a
+	// pointer copy is safe.
+	cp.nodeAffinity = r.nodeAffinity
+	// ...nor Preferences; carry it over the same way.
+	cp.Preferences = append([]PreferredTerm{}, r.Preferences...)
+	// ...nor requiredTerms; each requirementTerm is immutable once built (same as nodeAffinity), so
+	// copying the slice is enough. Without this, Add on a multi-required-term pod's Requirements
+	// (e.g. via Union) would silently collapse back to evaluating only the first required term.
+	cp.requiredTerms = append([]requirementTerm{}, r.requiredTerms...)
+	// The new requirements are AND'd into every required term as well, so Compatible enforces them
+	// whichever term it ends up accepting.
+	for i, term := range cp.requiredTerms {
+		folded := Requirements{requirements: term.requirements, ranges: term.ranges}.Add(requirements...)
+		cp.requiredTerms[i] = requirementTerm{requirements: folded.requirements, ranges: folded.ranges}
+	}
+	// zz_generated.deepcopy doesn't know about ranges either; copy it by hand so prior Gt/Lt
+	// bounds survive this Add call.
+	cp.ranges = map[string][]sets.Range{}
+	for key, rngs := range r.ranges {
+		cp.ranges[key] = append([]sets.Range{}, rngs...)
+	}
 	// This fail-safe measurement can be removed later when we implement test webhook.
 	if cp.requirements == nil {
 		cp.requirements = map[string]sets.Set{}
@@ -90,6 +263,14 @@ func (r Requirements) Add(requirements ...v1.NodeSelectorRequirement) Requiremen
 			continue
 		}
 		cp.Requirements = append(cp.Requirements, requirement)
+		// Gt/Lt narrow a key numerically rather than enumerate its values, so they're tracked
+		// alongside, not inside, the discrete Set for that key.
+		if requirement.Operator == v1.NodeSelectorOpGt || requirement.Operator == v1.NodeSelectorOpLt {
+			if value, err := strconv.ParseInt(sole(requirement.Values), 10, 64); err == nil {
+				cp.ranges[requirement.Key] = append(cp.ranges[requirement.Key], sets.NewRange(requirement.Operator, value))
+			}
+			continue
+		}
 		var values sets.Set
 		switch requirement.Operator {
 		case v1.NodeSelectorOpIn:
@@ -110,6 +291,15 @@ func (r Requirements) Add(requirements ...v1.NodeSelectorRequirement) Requiremen
 	return cp
 }
 
+// sole returns values[0], or "" if values doesn't contain exactly one element. Gt/Lt only accept a
+// single integer value; malformed input is left for Validate to report.
+func sole(values []string) string {
+	if len(values) != 1 {
+		return ""
+	}
+	return values[0]
+}
+
 // rebuild re-generates the node selector requirements based on the set based versions.  This improves scheduling speed
 // as it causes us to not carry around redundant requirements (e.g. 20x copies of instance-type in [it0, it1, ..., it400])
 func (r *Requirements) rebuild() {
@@ -174,7 +364,31 @@ func (r Requirements) Has(key string) bool {
 	return ok
 }
 
+// Get returns the discrete Set of values admitted for key, narrowed by any Gt/Lt bounds
+// registered for it.
 func (r Requirements) Get(key string) sets.Set {
+	values := r.rawSet(key)
+	for _, rng := range r.ranges[key] {
+		values = rng.Intersect(values)
+	}
+	return values
+}
+
+// rawSet returns the discrete Set tracked for key without applying this object's own Range
+// narrowing. A key bounded only by a Range (no enumerated values on this side) defaults to
+// unconstrained (Exists): Range.Intersect can't narrow an unenumerable Set, so the bound only
+// takes effect once it's applied to a concrete, enumerable Set - which may be this object's, or
+// may be the other side's in a cross-object comparison like Compatible. Get applies it here for
+// single-object callers (Zones, InstanceTypes, ...); Compatible applies both objects' Ranges to
+// both objects' rawSets itself, so a bound isn't lost just because the side declaring it has no
+// sibling In/NotIn Set for that key.
+func (r Requirements) rawSet(key string) sets.Set {
+	if values, ok := r.requirements[key]; ok {
+		return values
+	}
+	if _, hasRange := r.ranges[key]; hasRange {
+		return sets.NewComplementSet()
+	}
 	return r.requirements[key]
 }
 
@@ -211,27 +425,130 @@ func (r Requirements) Validate() (errs error) {
 				errs = multierr.Append(errs, fmt.Errorf("invalid value %s for key %s, %s", value, requirement.Key, err))
 			}
 		}
-		if !SupportedNodeSelectorOps.Has(string(requirement.Operator)) {
+		// SupportedNodeSelectorOps is the pre-existing allow-list (defined outside this file) and
+		// doesn't know about Gt/Lt; they're accepted here explicitly rather than by editing that
+		// shared list, since it's also consulted by non-numeric validation paths we don't own.
+		if !SupportedNodeSelectorOps.Has(string(requirement.Operator)) &&
+			requirement.Operator != v1.NodeSelectorOpGt && requirement.Operator != v1.NodeSelectorOpLt {
 			errs = multierr.Append(errs, fmt.Errorf("operator %s not in %s for key %s", requirement.Operator, SupportedNodeSelectorOps.UnsortedList(), requirement.Key))
 		}
+		if requirement.Operator == v1.NodeSelectorOpGt || requirement.Operator == v1.NodeSelectorOpLt {
+			if len(requirement.Values) != 1 {
+				errs = multierr.Append(errs, fmt.Errorf("%s requires exactly one value for key %s, got %d", requirement.Operator, requirement.Key, len(requirement.Values)))
+			} else if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("%s value %q for key %s must be an integer", requirement.Operator, requirement.Values[0], requirement.Key))
+			}
+		}
 	}
 	return errs
 }
 
-// Compatible ensures the provided requirements can be met.
+// Score returns the sum of weights of the Preferences candidate satisfies, mirroring the upstream
+// NodeAffinity plugin's Score extension point; it has no bearing on Compatible. Score is a scoring
+// primitive only - nothing in this package calls it yet. A caller ranking otherwise-feasible
+// instance types, zones, or capacity types (and relaxing a preference it can't satisfy for any of
+// them) would need to be added elsewhere before preferred affinity actually influences scheduling.
+func (r Requirements) Score(candidate Requirements) int64 {
+	var score int64
+	for _, p := range r.Preferences {
+		if p.Satisfied(candidate) {
+			score += int64(p.Weight)
+		}
+	}
+	return score
+}
+
+// Match reports whether node satisfies the pod's node selector and required node affinity in full,
+// honoring OR semantics between NodeSelectorTerms. Match is the authoritative check against a
+// concrete *v1.Node; Compatible narrows candidate instance types, zones, and capacity types before
+// any node exists, using the same OR semantics over whatever it can represent as discrete Sets and
+// Ranges.
+func (r Requirements) Match(node *v1.Node) (bool, error) {
+	if r.nodeAffinity == nil {
+		return true, nil
+	}
+	return r.nodeAffinity.Match(node)
+}
+
+// asTerm returns r's own requirements/ranges as a requirementTerm, for Compatible to fall back to
+// when an object wasn't built from more than one required NodeSelectorTerm.
+func (r Requirements) asTerm() requirementTerm {
+	return requirementTerm{requirements: r.requirements, ranges: r.ranges}
+}
+
+// terms returns the alternatives Compatible should try for r: requiredTerms if r was built from
+// more than one required NodeSelectorTerm (see NewPodRequirements), or r's own requirements/ranges
+// as the sole alternative otherwise.
+func (r Requirements) terms() []requirementTerm {
+	if len(r.requiredTerms) > 0 {
+		return r.requiredTerms
+	}
+	return []requirementTerm{r.asTerm()}
+}
+
+// Compatible ensures the provided requirements can be met. It reasons about the discrete,
+// AND-joined Sets and Ranges each requirementTerm tracks - for a pod with more than one required
+// NodeSelectorTerm, that's every term folded independently (see NewPodRequirements) - enough to
+// narrow candidate instance types, zones, and capacity types before any node exists. It tries every
+// combination of r's and requirements' terms and succeeds if any one combination is compatible,
+// mirroring the OR semantics Match gets from nodeAffinity; Match remains the authoritative check
+// once a concrete *v1.Node exists.
 func (r Requirements) Compatible(requirements Requirements) (errs error) {
-	for key, requirement := range requirements.requirements {
-		intersection := requirement.Intersection(r.Get(key))
+	for _, existing := range r.terms() {
+		for _, incoming := range requirements.terms() {
+			if err := compatibleTerms(incoming, existing); err == nil {
+				return nil
+			} else {
+				errs = multierr.Append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// compatibleTerms reports whether a single pair of requirementTerms admit a common value for every
+// key either one constrains, applying each side's Gt/Lt Ranges to both sides' raw Sets so a bound
+// declared on one side isn't lost just because that side has no sibling In/NotIn Set for the key.
+func compatibleTerms(requirement, existing requirementTerm) (errs error) {
+	keys := stringsets.NewString()
+	for key := range requirement.requirements {
+		keys.Insert(key)
+	}
+	// A key bounded only by Gt/Lt (no enumerated In/NotIn values on either side) doesn't appear in
+	// requirement.requirements, so it's picked up here too - from whichever side declares it.
+	for key := range requirement.ranges {
+		keys.Insert(key)
+	}
+	for key := range existing.ranges {
+		keys.Insert(key)
+	}
+	for _, key := range keys.UnsortedList() {
+		// A Gt/Lt bound only narrows a concrete, enumerable Set (Range.Intersect is a no-op on a
+		// complement Set), so a bound declared on one side with no sibling In/NotIn values for that
+		// key would never be enforced if we only let each side narrow itself via Get. Apply every
+		// Range registered for key, from both sides, to both sides' raw Sets before intersecting.
+		var ranges []sets.Range
+		ranges = append(ranges, requirement.ranges[key]...)
+		ranges = append(ranges, existing.ranges[key]...)
+		narrow := func(values sets.Set) sets.Set {
+			for _, rng := range ranges {
+				values = rng.Intersect(values)
+			}
+			return values
+		}
+		requirementValues := narrow(requirement.rawSet(key))
+		existingValues := narrow(existing.rawSet(key))
+		intersection := requirementValues.Intersection(existingValues)
 		// There must be some value, except in these cases
 		if intersection.Len() == 0 {
 			// Where incoming requirement has operator { NotIn, DoesNotExist }
-			if requirement.Type() == v1.NodeSelectorOpNotIn || requirement.Type() == v1.NodeSelectorOpDoesNotExist {
+			if requirementValues.Type() == v1.NodeSelectorOpNotIn || requirementValues.Type() == v1.NodeSelectorOpDoesNotExist {
 				// And existing requirement has operator { NotIn, DoesNotExist }
-				if r.Get(key).Type() == v1.NodeSelectorOpNotIn || r.Get(key).Type() == v1.NodeSelectorOpDoesNotExist {
+				if existingValues.Type() == v1.NodeSelectorOpNotIn || existingValues.Type() == v1.NodeSelectorOpDoesNotExist {
 					continue
 				}
 			}
-			errs = multierr.Append(errs, fmt.Errorf("%s not in %s, key %s", requirement, r.Get(key), key))
+			errs = multierr.Append(errs, fmt.Errorf("%s not in %s, key %s", requirementValues, existingValues, key))
 		}
 	}
 	return errs
@@ -271,5 +588,13 @@ func (r Requirements) String() string {
 		}
 		fmt.Fprintf(&sb, "%s %s %v", key, req.Type(), values)
 	}
+	for key, rngs := range r.ranges {
+		for _, rng := range rngs {
+			if sb.Len() > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "%s %s", key, rng)
+		}
+	}
 	return sb.String()
 }
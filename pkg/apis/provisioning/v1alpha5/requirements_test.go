@@ -0,0 +1,248 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha5
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A pod with only spec.nodeSelector set (no affinity block at all) is the common case, and Match
+// is the authoritative check once a concrete node exists - it must still enforce nodeSelector, not
+// silently pass every node because nodeAffinity was never attached.
+func TestMatchEnforcesNodeSelectorWithoutAffinity(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			NodeSelector: map[string]string{"disktype": "ssd"},
+		},
+	}
+	requirements := NewPodRequirements(pod)
+
+	hdd := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"disktype": "hdd"}}}
+	if ok, err := requirements.Match(hdd); err != nil {
+		t.Fatalf("unexpected error from Match: %s", err)
+	} else if ok {
+		t.Fatal("expected Match to reject a node that doesn't satisfy nodeSelector, got true")
+	}
+
+	ssd := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"disktype": "ssd"}}}
+	if ok, err := requirements.Match(ssd); err != nil {
+		t.Fatalf("unexpected error from Match: %s", err)
+	} else if !ok {
+		t.Fatal("expected Match to accept a node that satisfies nodeSelector, got false")
+	}
+}
+
+// Compatible must apply a Gt/Lt bound even when the side declaring it has no sibling In/NotIn
+// values for that key, narrowing the other side's discrete values instead of treating the bound as
+// unconstrained.
+func TestCompatibleMixedInAndRange(t *testing.T) {
+	bound := NewRequirements(v1.NodeSelectorRequirement{
+		Key:      "memory-tier",
+		Operator: v1.NodeSelectorOpGt,
+		Values:   []string{"4096"},
+	})
+	tooLow := NewRequirements(v1.NodeSelectorRequirement{
+		Key:      "memory-tier",
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{"2048", "4096"},
+	})
+	if err := tooLow.Compatible(bound); err == nil {
+		t.Fatal("expected Compatible to reject a candidate with no value satisfying the Gt bound, got nil error")
+	}
+
+	satisfies := NewRequirements(v1.NodeSelectorRequirement{
+		Key:      "memory-tier",
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{"2048", "8192"},
+	})
+	if err := satisfies.Compatible(bound); err != nil {
+		t.Fatalf("expected Compatible to accept a candidate with a value satisfying the Gt bound, got %s", err)
+	}
+
+	// A second, unrelated In key must still be AND'd in alongside the Gt/Lt-bounded key.
+	multiKey := NewRequirements(
+		v1.NodeSelectorRequirement{Key: "memory-tier", Operator: v1.NodeSelectorOpGt, Values: []string{"4096"}},
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+	)
+	wrongZone := NewRequirements(
+		v1.NodeSelectorRequirement{Key: "memory-tier", Operator: v1.NodeSelectorOpIn, Values: []string{"8192"}},
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+	)
+	if err := wrongZone.Compatible(multiKey); err == nil {
+		t.Fatal("expected Compatible to reject a candidate satisfying the Gt bound but not the unrelated In key, got nil error")
+	}
+	rightZone := NewRequirements(
+		v1.NodeSelectorRequirement{Key: "memory-tier", Operator: v1.NodeSelectorOpIn, Values: []string{"8192"}},
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+	)
+	if err := rightZone.Compatible(multiKey); err != nil {
+		t.Fatalf("expected Compatible to accept a candidate satisfying both the Gt bound and the In key, got %s", err)
+	}
+}
+
+// Compatible must try every required NodeSelectorTerm and succeed if any one is satisfiable,
+// mirroring Match's OR semantics instead of hard-coding the first term.
+func TestCompatibleTriesEveryRequiredTerm(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Affinity: &v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+						NodeSelectorTerms: []v1.NodeSelectorTerm{
+							{MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+							}},
+							{MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+	requirements := NewPodRequirements(pod)
+
+	candidate := NewRequirements(v1.NodeSelectorRequirement{
+		Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"},
+	})
+	if err := candidate.Compatible(requirements); err != nil {
+		t.Fatalf("expected Compatible to accept a candidate satisfying only the second required term, got %s", err)
+	}
+
+	notEither := NewRequirements(v1.NodeSelectorRequirement{
+		Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1c"},
+	})
+	if err := notEither.Compatible(requirements); err == nil {
+		t.Fatal("expected Compatible to reject a candidate satisfying neither required term, got nil error")
+	}
+}
+
+// A Gt/Lt preference bound with no sibling In/NotIn value for that key must still be enforced
+// against the candidate's discrete values, not silently treated as satisfied by any candidate that
+// merely has a value for the key.
+func TestPreferredTermSatisfied(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Affinity: &v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+						{
+							Weight: 10,
+							Preference: v1.NodeSelectorTerm{
+								MatchExpressions: []v1.NodeSelectorRequirement{
+									{Key: "gpu-count", Operator: v1.NodeSelectorOpGt, Values: []string{"4"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	requirements := NewPodRequirements(pod)
+
+	tooFew := NewRequirements(v1.NodeSelectorRequirement{Key: "gpu-count", Operator: v1.NodeSelectorOpIn, Values: []string{"0"}})
+	if score := requirements.Score(tooFew); score != 0 {
+		t.Fatalf("expected a candidate below the Gt bound to score 0, got %d", score)
+	}
+
+	enough := NewRequirements(v1.NodeSelectorRequirement{Key: "gpu-count", Operator: v1.NodeSelectorOpIn, Values: []string{"8"}})
+	if score := requirements.Score(enough); score != 10 {
+		t.Fatalf("expected a candidate above the Gt bound to score the term's weight, got %d", score)
+	}
+}
+
+// Union must fold added's requirements into r so a candidate has to satisfy both, and must reject
+// added outright (without touching r) if added itself is invalid.
+func TestUnion(t *testing.T) {
+	r := NewRequirements(v1.NodeSelectorRequirement{
+		Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"},
+	})
+	added := NewRequirements(v1.NodeSelectorRequirement{
+		Key: "capacity-pool", Operator: v1.NodeSelectorOpIn, Values: []string{"pool-1"},
+	})
+	unioned, err := r.Union(added)
+	if err != nil {
+		t.Fatalf("unexpected error from Union: %s", err)
+	}
+
+	missingPool := NewRequirements(v1.NodeSelectorRequirement{
+		Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"},
+	})
+	if err := missingPool.Compatible(unioned); err == nil {
+		t.Fatal("expected Compatible to reject a candidate missing the value Union added, got nil error")
+	}
+
+	satisfiesBoth := NewRequirements(
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+		v1.NodeSelectorRequirement{Key: "capacity-pool", Operator: v1.NodeSelectorOpIn, Values: []string{"pool-1"}},
+	)
+	if err := satisfiesBoth.Compatible(unioned); err != nil {
+		t.Fatalf("expected Compatible to accept a candidate satisfying both the original and added requirements, got %s", err)
+	}
+
+	invalid := NewRequirements(v1.NodeSelectorRequirement{
+		Key: v1.LabelTopologyZone, Operator: "Bogus", Values: []string{"test-zone-1a"},
+	})
+	if _, err := r.Union(invalid); err == nil {
+		t.Fatal("expected Union to reject an invalid added requirement, got nil error")
+	}
+}
+
+// Add (and therefore Union, which is built on it) must carry requiredTerms over to the copy it
+// returns. Otherwise a pod with more than one required NodeSelectorTerm loses every term but the
+// first the moment anything calls Add on its Requirements - exactly the OR-across-terms bug
+// Compatible was fixed for, reappearing one layer up.
+func TestAddPreservesRequiredTermsForMultiTermPod(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Affinity: &v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+						NodeSelectorTerms: []v1.NodeSelectorTerm{
+							{MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+							}},
+							{MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+	podRequirements := NewPodRequirements(pod)
+
+	added := NewRequirements(v1.NodeSelectorRequirement{
+		Key: "capacity-pool", Operator: v1.NodeSelectorOpIn, Values: []string{"pool-1"},
+	})
+	unioned, err := podRequirements.Union(added)
+	if err != nil {
+		t.Fatalf("unexpected error from Union: %s", err)
+	}
+
+	candidate := NewRequirements(
+		v1.NodeSelectorRequirement{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+		v1.NodeSelectorRequirement{Key: "capacity-pool", Operator: v1.NodeSelectorOpIn, Values: []string{"pool-1"}},
+	)
+	if err := candidate.Compatible(unioned); err != nil {
+		t.Fatalf("expected Compatible to accept a candidate satisfying the pod's second required term plus the added requirement, got %s", err)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sets
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	stringsets "k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Range is a numeric bound produced by the Gt/Lt NodeSelectorRequirement operators, which the
+// node-affinity scheduler plugin supports for numeric labels (e.g. GPU count, memory tier) but
+// which a discrete Set can't represent. Unlike Set, a Range doesn't enumerate admissible values;
+// it narrows a Set down to the numeric members that satisfy the bound.
+type Range struct {
+	operator v1.NodeSelectorOperator // NodeSelectorOpGt or NodeSelectorOpLt
+	value    int64
+}
+
+// NewRange returns a Range for the given Gt/Lt operator and bound value.
+func NewRange(operator v1.NodeSelectorOperator, value int64) Range {
+	return Range{operator: operator, value: value}
+}
+
+// Intersect filters s down to the members that satisfy the Range, dropping any value that isn't a
+// base-10 integer. A complement Set (NotIn/Exists) has no enumerable positive members to filter,
+// so it's returned unchanged; the bound is still enforced once it's intersected against a
+// concrete, enumerable Set on the other side of a Compatible check.
+func (r Range) Intersect(s Set) Set {
+	if s.IsComplement() {
+		return s
+	}
+	kept := stringsets.NewString()
+	for _, value := range s.Values().UnsortedList() {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		if (r.operator == v1.NodeSelectorOpGt && n > r.value) || (r.operator == v1.NodeSelectorOpLt && n < r.value) {
+			kept.Insert(value)
+		}
+	}
+	return NewSet(kept.UnsortedList()...)
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("%s %d", r.operator, r.value)
+}
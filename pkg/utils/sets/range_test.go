@@ -0,0 +1,82 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sets
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestRangeIntersect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		operator v1.NodeSelectorOperator
+		bound    int64
+		set      Set
+		want     []string
+	}{
+		{
+			name:     "Gt keeps only values above the bound",
+			operator: v1.NodeSelectorOpGt,
+			bound:    4096,
+			set:      NewSet("2048", "4096", "8192"),
+			want:     []string{"8192"},
+		},
+		{
+			name:     "Lt keeps only values below the bound",
+			operator: v1.NodeSelectorOpLt,
+			bound:    4096,
+			set:      NewSet("2048", "4096", "8192"),
+			want:     []string{"2048"},
+		},
+		{
+			name:     "non-integer members are dropped rather than erroring",
+			operator: v1.NodeSelectorOpGt,
+			bound:    0,
+			set:      NewSet("not-a-number", "1"),
+			want:     []string{"1"},
+		},
+		{
+			name:     "a complement Set has nothing to filter and passes through unchanged",
+			operator: v1.NodeSelectorOpGt,
+			bound:    4096,
+			set:      NewComplementSet("2048"),
+			want:     nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewRange(tc.operator, tc.bound).Intersect(tc.set)
+			if tc.set.IsComplement() {
+				if !got.IsComplement() || !got.ComplementValues().Equal(tc.set.ComplementValues()) {
+					t.Fatalf("expected a complement Set to pass through Intersect unchanged, got %v", got)
+				}
+				return
+			}
+			if got.IsComplement() {
+				t.Fatalf("expected a concrete Set, got a complement Set")
+			}
+			gotList := got.Values().List()
+			if len(gotList) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, gotList)
+			}
+			for _, v := range tc.want {
+				if !got.Has(v) {
+					t.Fatalf("expected %v to contain %q, got %v", tc.want, v, gotList)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sets
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	stringsets "k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Set is an efficient set-based representation of a NodeSelectorRequirement's values. A Set is
+// either a plain set of values (In) or the complement of a set of values (NotIn); Exists and
+// DoesNotExist are represented as the complement of the empty set and the empty set, respectively.
+type Set struct {
+	values     stringsets.String
+	complement bool
+}
+
+// NewSet returns a Set containing exactly values (used for In and DoesNotExist).
+func NewSet(values ...string) Set {
+	return Set{values: stringsets.NewString(values...)}
+}
+
+// NewComplementSet returns a Set containing every value except values (used for NotIn and Exists).
+func NewComplementSet(values ...string) Set {
+	return Set{values: stringsets.NewString(values...), complement: true}
+}
+
+// IsComplement returns true if this Set was built with NewComplementSet.
+func (s Set) IsComplement() bool {
+	return s.complement
+}
+
+// Values returns the Set's members. It's only meaningful when IsComplement is false.
+func (s Set) Values() stringsets.String {
+	if s.complement {
+		return stringsets.NewString()
+	}
+	return s.values
+}
+
+// ComplementValues returns the values excluded from the Set. It's only meaningful when
+// IsComplement is true.
+func (s Set) ComplementValues() stringsets.String {
+	if !s.complement {
+		return stringsets.NewString()
+	}
+	return s.values
+}
+
+// Len returns the number of values the Set admits. A complement Set admits every value not in its
+// exclusion list, so unless that list is the universe of possible values (which never happens in
+// practice), it always admits at least one value.
+func (s Set) Len() int {
+	if s.complement {
+		return 1
+	}
+	return s.values.Len()
+}
+
+// Has returns true if value is admitted by the Set.
+func (s Set) Has(value string) bool {
+	if s.complement {
+		return !s.values.Has(value)
+	}
+	return s.values.Has(value)
+}
+
+// Type returns the NodeSelectorOperator this Set behaves as.
+func (s Set) Type() v1.NodeSelectorOperator {
+	switch {
+	case s.complement && s.values.Len() == 0:
+		return v1.NodeSelectorOpExists
+	case s.complement:
+		return v1.NodeSelectorOpNotIn
+	case s.values.Len() == 0:
+		return v1.NodeSelectorOpDoesNotExist
+	default:
+		return v1.NodeSelectorOpIn
+	}
+}
+
+// String renders the Set the same way Range does, so callers formatting a key's admitted values
+// (e.g. Requirements.Compatible's mismatch errors) don't need to special-case complement Sets.
+func (s Set) String() string {
+	return fmt.Sprintf("%s %v", s.Type(), s.values.List())
+}
+
+// Intersection returns the Set of values admitted by both s and other.
+func (s Set) Intersection(other Set) Set {
+	switch {
+	case s.complement && other.complement:
+		return Set{values: s.values.Union(other.values), complement: true}
+	case s.complement:
+		return Set{values: other.values.Difference(s.values)}
+	case other.complement:
+		return Set{values: s.values.Difference(other.values)}
+	default:
+		return Set{values: s.values.Intersection(other.values)}
+	}
+}